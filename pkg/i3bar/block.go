@@ -0,0 +1,81 @@
+package i3bar
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/vincent-petithory/structfield"
+)
+
+var trueBoolTransformer = structfield.TransformerFunc(func(field string, value interface{}) (string, interface{}) {
+	switch x := value.(type) {
+	case bool:
+		if !x {
+			return field, false
+		}
+	default:
+		panic("trueBoolTransformer: expected bool")
+	}
+	return "", nil
+})
+
+// Block defines the struct of blocks in the i3bar protocol.
+type Block struct {
+	FullText            string `json:"full_text"`
+	ShortText           string `json:"short_text,omitempty"`
+	Color               string `json:"color,omitempty"`
+	MinWidth            int    `json:"min_width,omitempty"`
+	Align               string `json:"align,omitempty"`
+	Name                string `json:"name,omitempty"`
+	Instance            string `json:"instance,omitempty"`
+	Urgent              bool   `json:"urgent,omitempty"`
+	Separator           bool   `json:"separator"`
+	SeparatorBlockWidth int    `json:"separator_block_width,omitempty"`
+}
+
+func (b Block) MarshalJSON() ([]byte, error) {
+	m := structfield.Transform(b, map[string]structfield.Transformer{
+		"separator": trueBoolTransformer,
+	})
+	return json.Marshal(m)
+}
+
+func (b *Block) UnmarshalJSON(data []byte) error {
+	type blockAlias Block
+	ba := blockAlias{}
+	if err := json.Unmarshal(data, &ba); err != nil {
+		return err
+	}
+	*b = Block(ba)
+
+	sep := struct {
+		Value *bool `json:"separator"`
+	}{}
+	if err := json.Unmarshal(data, &sep); err != nil {
+		return err
+	}
+	if sep.Value != nil {
+		b.Separator = *sep.Value
+	} else {
+		// defaults to true
+		b.Separator = true
+	}
+	return nil
+}
+
+// String implements Stringer interface.
+func (b Block) String() string {
+	return b.FullText
+}
+
+// EncodeBlock encodes block to w, as a single JSON object if single is true,
+// or as a one-element JSON array otherwise.
+func EncodeBlock(w io.Writer, block Block, single bool) error {
+	var v interface{}
+	if single {
+		v = block
+	} else {
+		v = []Block{block}
+	}
+	return json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,43 @@
+package i3bar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ClickEvent holds data sent by i3bar when the user clicks a block.
+type ClickEvent struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	Button   int    `json:"button"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+}
+
+// DecodeClickEvent reads a single click event JSON payload from r and writes
+// the value of field to w.
+// Possible fields are name, instance, button, x, y.
+func DecodeClickEvent(w io.Writer, r io.Reader, field string) error {
+	var ce ClickEvent
+	if err := json.NewDecoder(r).Decode(&ce); err != nil {
+		return err
+	}
+	var v interface{}
+	switch field {
+	case "name":
+		v = ce.Name
+	case "instance":
+		v = ce.Instance
+	case "button":
+		v = ce.Button
+	case "x":
+		v = ce.X
+	case "y":
+		v = ce.Y
+	default:
+		return fmt.Errorf("unknown property %s", field)
+	}
+	fmt.Fprintln(w, v)
+	return nil
+}
@@ -0,0 +1,12 @@
+// Package i3bar implements the types and JSON framing of the i3bar protocol,
+// as documented at https://i3wm.org/docs/i3bar-protocol.html.
+package i3bar
+
+// Header defines the struct of the header in the i3bar protocol.
+type Header struct {
+	Version     int    `json:"version"`
+	StopSignal  int    `json:"stop_signal,omitempty"`
+	ContSignal  int    `json:"cont_signal,omitempty"`
+	ClickEvents bool   `json:"click_events,omitempty"`
+	Markup      string `json:"markup,omitempty"`
+}
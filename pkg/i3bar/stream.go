@@ -0,0 +1,230 @@
+package i3bar
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+// Stream implements the framing of the i3bar protocol on top of a reader
+// and/or a writer: the optional header line and its opening "[", the
+// leading commas between one JSON value and the next, and the trailing
+// comma written after every update. A Block or a ClickEvent is the atomic
+// unit of communication; callers only ever see whole messages through
+// ReadBlocks/WriteBlocks and ReadClickEvent/WriteClickEvent, never the
+// framing bytes around them.
+type Stream struct {
+	r          *bufio.Reader
+	dec        *json.Decoder
+	headerSeen bool
+
+	w io.Writer
+}
+
+// NewStream returns a Stream reading messages from r and/or writing them to
+// w. Either may be nil if the Stream is only ever used in one direction.
+func NewStream(r io.Reader, w io.Writer) *Stream {
+	s := &Stream{w: w}
+	if r != nil {
+		s.r = bufio.NewReader(r)
+		s.dec = json.NewDecoder(s.r)
+	}
+	return s
+}
+
+// skipFraming consumes whatever separates one message from the next: an
+// optional leading header line and the top-level array's opening "[" (only
+// ever present once, at the very start of the stream), then any run of
+// whitespace and "," before the next JSON value. It must never treat a
+// later "[" as skippable framing: for ReadBlocks, every message is itself a
+// JSON array, so its opening "[" is part of the value being decoded, not a
+// separator.
+func (s *Stream) skipFraming() error {
+	if !s.headerSeen {
+		s.headerSeen = true
+		ruune, _, err := s.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if ruune == '{' {
+			// Consume the header line, then the line with the opening [.
+			if _, err := s.r.ReadString('\n'); err != nil {
+				return err
+			}
+			if _, err := s.r.ReadString('\n'); err != nil {
+				return err
+			}
+		} else {
+			// No header: the stream starts directly with the top-level
+			// array's opening "[".
+			if err := s.r.UnreadRune(); err != nil {
+				return err
+			}
+			if err := s.skipTopLevelOpen(); err != nil {
+				return err
+			}
+		}
+	}
+	return s.skipSeparators()
+}
+
+// skipTopLevelOpen consumes the leading whitespace and the single "[" that
+// opens the never-closed top-level array.
+func (s *Stream) skipTopLevelOpen() error {
+	for {
+		ruune, _, err := s.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if unicode.IsSpace(ruune) {
+			continue
+		}
+		if ruune != '[' {
+			return fmt.Errorf("i3bar: expected top-level '[', got %q", ruune)
+		}
+		return nil
+	}
+}
+
+// skipSeparators consumes any run of whitespace and "," before the next
+// JSON value.
+func (s *Stream) skipSeparators() error {
+	for {
+		ruune, _, err := s.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if isSeparatorRune(ruune) {
+			continue
+		}
+		return s.r.UnreadRune()
+	}
+}
+
+// isSeparatorRune reports whether r is one of the bytes written between one
+// JSON value and the next: whitespace or the "," written after every update.
+func isSeparatorRune(r rune) bool {
+	return unicode.IsSpace(r) || r == ','
+}
+
+// reclaimBuffered folds back whatever json.Decoder.Decode read ahead of the
+// value it just returned into s.r, and builds a fresh decoder on top of it.
+// Decode reads from s.r in chunks rather than byte by byte, so after it
+// returns, bytes belonging to the next message can already be sitting in
+// the decoder's own internal buffer rather than s.r; without this, a raw
+// s.r.ReadRune() in skipFraming/resync would see EOF instead of them.
+func (s *Stream) reclaimBuffered() {
+	s.r = bufio.NewReader(io.MultiReader(s.dec.Buffered(), s.r))
+	s.dec = json.NewDecoder(s.r)
+}
+
+// decodeErrorOffset returns how many bytes into the data handed to Decode
+// the error occurred, or 0 if err doesn't carry that information. Decode
+// itself doesn't consume any bytes on error: Buffered() hands back the
+// whole value it was attempting to parse, offending bytes included, so
+// resync needs this to know how much of that to discard before it can look
+// for the next value instead of tripping over the same bytes again.
+func decodeErrorOffset(err error) int64 {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return e.Offset
+	case *json.UnmarshalTypeError:
+		return e.Offset
+	default:
+		return 0
+	}
+}
+
+// resync recovers from a decode error by discarding whatever the decoder had
+// already buffered, skipping past the bytes of the value that failed to
+// parse, then scanning forward to the next startRune so the following Read
+// call starts clean on a whole value. startRune must be the character that
+// opens the kind of value being read: "[" for a block list, "{" for a click
+// event. A plain separator skip isn't enough here, since a malformed value
+// can itself contain stray "}"/"]" bytes that a pure whitespace/","-skip
+// would feed straight back into the next Decode.
+func (s *Stream) resync(err error, startRune rune) {
+	s.reclaimBuffered()
+	if n := decodeErrorOffset(err); n > 0 {
+		s.r.Discard(int(n))
+	} else {
+		// No offset to go on; skip at least one rune so a value that's
+		// malformed from its very first byte can't wedge us in place.
+		s.r.ReadRune()
+	}
+	for {
+		ruune, _, rerr := s.r.ReadRune()
+		if rerr != nil {
+			break
+		}
+		if ruune == startRune {
+			s.r.UnreadRune()
+			break
+		}
+	}
+	s.dec = json.NewDecoder(s.r)
+}
+
+// ReadBlocks reads the next block list message from the stream.
+func (s *Stream) ReadBlocks() ([]*Block, error) {
+	if err := s.skipFraming(); err != nil {
+		return nil, err
+	}
+	var blocks []*Block
+	err := s.dec.Decode(&blocks)
+	if err != nil {
+		if err != io.EOF {
+			s.resync(err, '[')
+		}
+		return nil, err
+	}
+	s.reclaimBuffered()
+	return blocks, nil
+}
+
+// WriteBlocks writes blocks as the next message on the stream, followed by
+// the trailing comma the i3bar protocol's never-closed top-level array
+// needs between updates.
+func (s *Stream) WriteBlocks(blocks []*Block) error {
+	if err := json.NewEncoder(s.w).Encode(blocks); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.w, ",")
+	return err
+}
+
+// ReadClickEvent reads the next click event message from the stream.
+func (s *Stream) ReadClickEvent() (ClickEvent, error) {
+	if err := s.skipFraming(); err != nil {
+		return ClickEvent{}, err
+	}
+	var ce ClickEvent
+	err := s.dec.Decode(&ce)
+	if err != nil {
+		if err != io.EOF {
+			s.resync(err, '{')
+		}
+		return ClickEvent{}, err
+	}
+	s.reclaimBuffered()
+	return ce, nil
+}
+
+// WriteClickEvent writes ce as the next message on the stream.
+func (s *Stream) WriteClickEvent(ce ClickEvent) error {
+	return json.NewEncoder(s.w).Encode(ce)
+}
+
+// WriteHeader writes the i3bar header line and the opening "[" of the
+// never-closed top-level array that follows it. It must be called at most
+// once, before any call to WriteBlocks.
+func (s *Stream) WriteHeader(h Header) error {
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "%s\n[\n", hb)
+	return err
+}
@@ -0,0 +1,91 @@
+package i3bar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStreamReadBlocks(t *testing.T) {
+	input := "{\"version\":1}\n[\n" +
+		"[{\"full_text\":\"a\"}],\n" +
+		"[{\"full_text\":\"b\"}],\n"
+	s := NewStream(strings.NewReader(input), nil)
+
+	for _, want := range []string{"a", "b"} {
+		blocks, err := s.ReadBlocks()
+		if err != nil {
+			t.Fatalf("ReadBlocks: %v", err)
+		}
+		if len(blocks) != 1 || blocks[0].FullText != want {
+			t.Fatalf("ReadBlocks = %+v, want a single block with FullText %q", blocks, want)
+		}
+	}
+
+	if _, err := s.ReadBlocks(); err == nil {
+		t.Fatalf("ReadBlocks at end of stream: got nil error, want EOF")
+	}
+}
+
+func TestStreamReadBlocksResyncAfterDecodeError(t *testing.T) {
+	// The first update is malformed (a trailing comma instead of a value);
+	// the second is well-formed and must still be read correctly.
+	input := "[\n[{\"bad\":,}],\n[{\"full_text\":\"ok\"}],\n"
+	s := NewStream(strings.NewReader(input), nil)
+
+	if _, err := s.ReadBlocks(); err == nil {
+		t.Fatalf("ReadBlocks on malformed update: got nil error, want a decode error")
+	}
+
+	blocks, err := s.ReadBlocks()
+	if err != nil {
+		t.Fatalf("ReadBlocks after resync: %v", err)
+	}
+	if len(blocks) != 1 || blocks[0].FullText != "ok" {
+		t.Fatalf("ReadBlocks after resync = %+v, want a single block with FullText %q", blocks, "ok")
+	}
+}
+
+func TestStreamReadClickEvent(t *testing.T) {
+	input := "[\n" +
+		"{\"name\":\"a\",\"button\":1},\n" +
+		"{\"name\":\"b\",\"button\":2},\n"
+	s := NewStream(strings.NewReader(input), nil)
+
+	for _, want := range []string{"a", "b"} {
+		ce, err := s.ReadClickEvent()
+		if err != nil {
+			t.Fatalf("ReadClickEvent: %v", err)
+		}
+		if ce.Name != want {
+			t.Fatalf("ReadClickEvent.Name = %q, want %q", ce.Name, want)
+		}
+	}
+}
+
+func TestStreamReadClickEventResyncAfterDecodeError(t *testing.T) {
+	// Unlike a block list, a click event past the first one in the stream
+	// is only ever preceded by "," rather than "[", so resync must not
+	// hunt for "[" here or it would hang forever.
+	input := "[\n{\"name\":\"a\",\"button\":1},\nnotjson,\n{\"name\":\"b\",\"button\":2},\n"
+	s := NewStream(strings.NewReader(input), nil)
+
+	ce1, err := s.ReadClickEvent()
+	if err != nil {
+		t.Fatalf("ReadClickEvent(1): %v", err)
+	}
+	if ce1.Name != "a" {
+		t.Fatalf("ReadClickEvent(1).Name = %q, want %q", ce1.Name, "a")
+	}
+
+	if _, err := s.ReadClickEvent(); err == nil {
+		t.Fatalf("ReadClickEvent on malformed event: got nil error, want a decode error")
+	}
+
+	ce3, err := s.ReadClickEvent()
+	if err != nil {
+		t.Fatalf("ReadClickEvent after resync: %v", err)
+	}
+	if ce3.Name != "b" {
+		t.Fatalf("ReadClickEvent after resync.Name = %q, want %q", ce3.Name, "b")
+	}
+}
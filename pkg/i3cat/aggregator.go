@@ -0,0 +1,214 @@
+package i3cat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/vincent-petithory/i3cat/pkg/i3bar"
+)
+
+// BlockAggregate relates a BlockSource to the Blocks it produced during one update.
+type BlockAggregate struct {
+	Source BlockSource
+	Blocks []*i3bar.Block
+}
+
+// BlockAggregator fans-in all Blocks produced by a list of BlockSources and sends it to the writer W.
+// Sources can be a mix of CmdIO (subprocesses speaking the i3bar protocol) and
+// any other in-process BlockSource implementation. Sources can be added and
+// removed while Aggregate and ForwardClickEvents are running; mu guards the
+// fields they all touch.
+type BlockAggregator struct {
+	// Blocks keeps track of which BlockSource produced which Block list.
+	Blocks map[BlockSource][]*i3bar.Block
+	// Sources keeps an ordered list of the BlockSources being aggregated.
+	Sources []BlockSource
+	// Out is where multiplexed block updates are written to, framed as
+	// the i3bar protocol prescribes.
+	Out *i3bar.Stream
+	// GlobalTransformers run, in order, on every aggregated update just
+	// before it's written to Out, after any per-source transformers.
+	GlobalTransformers []BlockTransformer
+
+	log Logger
+
+	mu sync.RWMutex
+	// clicks holds the click-event channel handed to each source's Run.
+	clicks map[BlockSource]chan i3bar.ClickEvent
+	// prefixes holds the namePrefix each source was added with, so
+	// ForwardClickEvents can strip it back off before handing a click event
+	// to the source it came from.
+	prefixes map[BlockSource]string
+	// cancels stops the per-source context given to each source's Run.
+	cancels map[BlockSource]context.CancelFunc
+
+	pauseMu sync.Mutex
+	paused  bool
+}
+
+// NewBlockAggregator returns a BlockAggregator which will write to out,
+// logging through logger.
+func NewBlockAggregator(out *i3bar.Stream, logger Logger) *BlockAggregator {
+	return &BlockAggregator{
+		Blocks:   make(map[BlockSource][]*i3bar.Block),
+		Sources:  make([]BlockSource, 0),
+		Out:      out,
+		log:      logger,
+		clicks:   make(map[BlockSource]chan i3bar.ClickEvent),
+		prefixes: make(map[BlockSource]string),
+		cancels:  make(map[BlockSource]context.CancelFunc),
+	}
+}
+
+// AddSource registers src as one more BlockSource to aggregate and starts it
+// running in its own goroutine, under a context derived from ctx. Every
+// BlockAggregate it produces is sent on blockAggregatesCh, which Aggregate
+// reads from. src stops either when ctx is canceled or when it is passed to
+// RemoveSource.
+//
+// namePrefix is the prefix, if any, an AutoPrefixer transformer in
+// transformers applies to src's blocks; ForwardClickEvents strips it back
+// off before handing a click event to src, since src never emitted a block
+// under the prefixed name itself. Pass "" if no AutoPrefixer is in use.
+//
+// transformers, if any, run in order on every update src produces before
+// it's handed to the aggregator, letting a single source be rate-limited,
+// re-themed, etc. independently of the others.
+func (ba *BlockAggregator) AddSource(ctx context.Context, src BlockSource, blockAggregatesCh chan<- *BlockAggregate, namePrefix string, transformers ...BlockTransformer) {
+	srcCtx, cancel := context.WithCancel(ctx)
+
+	ba.mu.Lock()
+	ba.Sources = append(ba.Sources, src)
+	clicksCh := make(chan i3bar.ClickEvent)
+	ba.clicks[src] = clicksCh
+	ba.prefixes[src] = namePrefix
+	ba.cancels[src] = cancel
+	ba.mu.Unlock()
+
+	blocksCh := make(chan []*i3bar.Block)
+	go func() {
+		defer close(blocksCh)
+		if err := src.Run(srcCtx, blocksCh, clicksCh); err != nil && err != context.Canceled {
+			ba.log.Error("msg", "block source stopped", "err", err)
+		}
+	}()
+	go func() {
+		for b := range blocksCh {
+			for _, t := range transformers {
+				b = t(b)
+			}
+			blockAggregatesCh <- &BlockAggregate{Source: src, Blocks: b}
+		}
+	}()
+}
+
+// RemoveSource stops src and forgets it, so future updates are aggregated
+// without it. It does not close src itself; the caller remains responsible
+// for any resource src owns outside of Run, such as a CmdIO's process.
+func (ba *BlockAggregator) RemoveSource(src BlockSource) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+	if cancel, ok := ba.cancels[src]; ok {
+		cancel()
+		delete(ba.cancels, src)
+	}
+	delete(ba.Blocks, src)
+	delete(ba.clicks, src)
+	delete(ba.prefixes, src)
+	for i, s := range ba.Sources {
+		if s == src {
+			ba.Sources = append(ba.Sources[:i], ba.Sources[i+1:]...)
+			break
+		}
+	}
+}
+
+// Pause stops Aggregate from writing further updates to Out, without
+// dropping the BlockSources tracked or the updates they keep producing.
+// It's meant to be called when i3bar asks us to pause via stop_signal.
+func (ba *BlockAggregator) Pause() {
+	ba.pauseMu.Lock()
+	ba.paused = true
+	ba.pauseMu.Unlock()
+}
+
+// Resume undoes Pause, letting Aggregate write updates to Out again.
+func (ba *BlockAggregator) Resume() {
+	ba.pauseMu.Lock()
+	ba.paused = false
+	ba.pauseMu.Unlock()
+}
+
+func (ba *BlockAggregator) isPaused() bool {
+	ba.pauseMu.Lock()
+	defer ba.pauseMu.Unlock()
+	return ba.paused
+}
+
+// Aggregate starts aggregating data coming from the BlockAggregates channel.
+func (ba *BlockAggregator) Aggregate(blockAggregates <-chan *BlockAggregate) {
+	for blockAggregate := range blockAggregates {
+		ba.mu.Lock()
+		ba.Blocks[blockAggregate.Source] = blockAggregate.Blocks
+		if ba.isPaused() {
+			ba.mu.Unlock()
+			continue
+		}
+		blocksUpdate := make([]*i3bar.Block, 0)
+		for _, src := range ba.Sources {
+			blocksUpdate = append(blocksUpdate, ba.Blocks[src]...)
+		}
+		ba.mu.Unlock()
+		for _, t := range ba.GlobalTransformers {
+			blocksUpdate = t(blocksUpdate)
+		}
+		if err := ba.Out.WriteBlocks(blocksUpdate); err != nil {
+			ba.log.Error("msg", "failed to write blocks", "err", err)
+		}
+	}
+}
+
+// ForwardClickEvents relays click events emitted on ceCh to interested parties.
+// An interested party is a BlockSource whose last reported Blocks contains one
+// matching the event's name/instance; the event, with any AutoPrefixer
+// namePrefix stripped back off its Name, is then handed to that source's own
+// click channel, whether it's backed by a subprocess or an in-process
+// implementation.
+func (ba *BlockAggregator) ForwardClickEvents(ceCh <-chan i3bar.ClickEvent) {
+	for ce := range ceCh {
+		clicksCh, ok := ba.clicksChanFor(&ce)
+		if !ok {
+			ba.log.Warn("msg", "no block source found for click event", "event", fmt.Sprintf("%+v", ce))
+			continue
+		}
+		clicksCh <- ce
+	}
+}
+
+// clicksChanFor finds the click channel of the source whose last reported
+// Blocks contains one matching ce's name/instance, stripping any
+// AutoPrefixer namePrefix back off ce.Name. The match, the channel lookup
+// and the prefix strip all happen under the same RLock so a concurrent
+// RemoveSource can't be observed halfway through: either this sees the
+// source's channel still registered, or it doesn't find the source at all.
+func (ba *BlockAggregator) clicksChanFor(ce *i3bar.ClickEvent) (chan i3bar.ClickEvent, bool) {
+	ba.mu.RLock()
+	defer ba.mu.RUnlock()
+	for _, src := range ba.Sources {
+		for _, block := range ba.Blocks[src] {
+			if block.Name == ce.Name && block.Instance == ce.Instance {
+				// One of the blocks of this source matched.
+				// We don't want more since a name/instance is supposed to be unique.
+				clicksCh, ok := ba.clicks[src]
+				if !ok {
+					return nil, false
+				}
+				ce.Name = strings.TrimPrefix(ce.Name, ba.prefixes[src])
+				return clicksCh, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,57 @@
+package i3cat
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/vincent-petithory/i3cat/pkg/i3bar"
+)
+
+// ClickEventsListener parses the click event stream and notifies its subscribers.
+type ClickEventsListener struct {
+	stream          *i3bar.Stream
+	clickEventChans []chan i3bar.ClickEvent
+	log             Logger
+}
+
+// NewClickEventsListener returns a ClickEventsListener which reads from r,
+// logging through logger.
+func NewClickEventsListener(r io.Reader, logger Logger) *ClickEventsListener {
+	return &ClickEventsListener{
+		stream:          i3bar.NewStream(r, nil),
+		clickEventChans: make([]chan i3bar.ClickEvent, 0),
+		log:             logger,
+	}
+}
+
+// Listen reads and decodes the click event stream and forwards them to the
+// channels subscribers. A malformed click event is logged and skipped: the
+// underlying Stream has already resynced to the next value, so Listen keeps
+// reading rather than giving up on click events for the rest of the process.
+func (cel *ClickEventsListener) Listen() {
+	for {
+		ce, err := cel.stream.ReadClickEvent()
+		switch {
+		case err == io.EOF:
+			cel.log.Info("msg", "ClickEventsListener: reached EOF")
+			return
+		case err != nil:
+			cel.log.Warn("msg", "ClickEventsListener: invalid JSON input", "err", err)
+			continue
+		default:
+			cel.log.Debug("msg", "received click event", "event", fmt.Sprintf("%+v", ce))
+			for _, ch := range cel.clickEventChans {
+				go func(ch chan i3bar.ClickEvent) {
+					ch <- ce
+				}(ch)
+			}
+		}
+	}
+}
+
+// Notify returns a channel which will be fed by incoming ClickEvents.
+func (cel *ClickEventsListener) Notify() chan i3bar.ClickEvent {
+	ch := make(chan i3bar.ClickEvent)
+	cel.clickEventChans = append(cel.clickEventChans, ch)
+	return ch
+}
@@ -0,0 +1,128 @@
+// Package i3cat implements the aggregation of several i3bar block sources
+// into a single i3bar protocol stream, as well as the routing of click
+// events back to the source that produced the clicked block.
+package i3cat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/vincent-petithory/i3cat/pkg/i3bar"
+)
+
+// A CmdIO defines a cmd that will feed the i3bar.
+type CmdIO struct {
+	// Cmd is the command being run
+	Cmd *exec.Cmd
+	// reader is the underlying stream where Cmd outputs data.
+	reader io.ReadCloser
+	// writer is the underlying stream where Cmd outputs data.
+	writer io.WriteCloser
+	// stream frames Cmd's stdout/stdin as i3bar protocol messages.
+	stream *i3bar.Stream
+	// log is scoped with this command's source=<cmd> field.
+	log Logger
+}
+
+// NewCmdIO creates a new CmdIO from command c, logging through logger.
+// c must be properly quoted for a shell as it's passed to sh -c.
+func NewCmdIO(c string, logger Logger) (*CmdIO, error) {
+	cmd := exec.Command(os.Getenv("SHELL"), "-c", c)
+	// Run the command in its own process group so a signal can be sent to
+	// it and whatever children it spawns in one go, via Signal.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	reader, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	writer, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	cmdio := CmdIO{
+		Cmd:    cmd,
+		reader: reader,
+		writer: writer,
+		stream: i3bar.NewStream(reader, writer),
+		log:    With(logger, "source", c),
+	}
+	return &cmdio, nil
+}
+
+// Run starts the command of CmdIO and implements BlockSource, feeding out
+// with the Blocks it produces and forwarding clicks to the command's stdin,
+// until ctx is canceled or the command's output is exhausted.
+func (c *CmdIO) Run(ctx context.Context, out chan<- []*i3bar.Block, clicks <-chan i3bar.ClickEvent) error {
+	if err := c.Cmd.Start(); err != nil {
+		return err
+	}
+
+	go c.forwardClicks(ctx, clicks)
+
+	for {
+		b, err := c.stream.ReadBlocks()
+		if err != nil {
+			if err == io.EOF {
+				c.log.Info("msg", "reached EOF")
+				return nil
+			}
+			c.log.Warn("msg", "invalid JSON input: all decoding methods failed", "err", err)
+			// send an error block
+			b = []*i3bar.Block{
+				{
+					FullText: fmt.Sprintf("Error parsing input: %v", err),
+					Color:    "#FF0000",
+				},
+			}
+		}
+		select {
+		case out <- b:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// forwardClicks relays click events addressed to this command to its stdin,
+// as the i3bar protocol prescribes, until ctx is canceled or clicks closes.
+func (c *CmdIO) forwardClicks(ctx context.Context, clicks <-chan i3bar.ClickEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ce, ok := <-clicks:
+			if !ok {
+				return
+			}
+			if err := c.stream.WriteClickEvent(ce); err != nil {
+				c.log.Error("msg", "failed to write click event", "err", err)
+			}
+			c.log.Debug("msg", "sending click event", "event", fmt.Sprintf("%+v", ce))
+		}
+	}
+}
+
+// Signal sends sig to the command's whole process group, so that a script
+// piping through other processes is paused or resumed as a unit.
+func (c *CmdIO) Signal(sig syscall.Signal) error {
+	if c.Cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-c.Cmd.Process.Pid, sig)
+}
+
+// Close closes reader and writers of this CmdIO.
+func (c *CmdIO) Close() error {
+	if err := c.reader.Close(); err != nil {
+		return err
+	}
+	if err := c.writer.Close(); err != nil {
+		return err
+	}
+	return nil
+}
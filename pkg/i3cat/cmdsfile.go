@@ -0,0 +1,142 @@
+package i3cat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cmdSpec is one command listed in the cmd-file, together with the chain
+// of BlockTransformers configured by the "!filter" lines preceding it.
+type cmdSpec struct {
+	cmd     string
+	filters []BlockTransformer
+	// namePrefix is the argument of a "!filter prefix" line preceding cmd,
+	// or "" if there was none. It's threaded through to
+	// BlockAggregator.AddSource separately from filters so a click event
+	// routed back to this command can have the prefix stripped again.
+	namePrefix string
+}
+
+// parseCmdsFile reads the cmd-file format: blank lines and lines starting
+// with "#" are ignored. A line of the form "!filter NAME ARGS..." appends a
+// built-in BlockTransformer to the chain that will be applied to the next
+// command line; any number of "!filter" lines can precede a command.
+func parseCmdsFile(r io.Reader) ([]cmdSpec, error) {
+	var specs []cmdSpec
+	var pending []BlockTransformer
+	var pendingPrefix string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			// Skip
+		case strings.HasPrefix(line, "!filter "):
+			args := strings.TrimPrefix(line, "!filter ")
+			t, err := parseFilterLine(args)
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, t)
+			if fields := strings.Fields(args); len(fields) == 2 && fields[0] == "prefix" {
+				pendingPrefix = fields[1]
+			}
+		default:
+			specs = append(specs, cmdSpec{cmd: line, filters: pending, namePrefix: pendingPrefix})
+			pending = nil
+			pendingPrefix = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// readCmdSpecs opens cmdsFile (or reads STDIN if it's "-") and parses it.
+// It is used both at startup and to reload the cmd-file on SIGHUP.
+func readCmdSpecs(cmdsFile string) ([]cmdSpec, error) {
+	var r io.ReadCloser
+	if cmdsFile == "-" {
+		r = ioutil.NopCloser(os.Stdin)
+	} else {
+		f, err := os.Open(os.ExpandEnv(cmdsFile))
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	}
+	specs, err := parseCmdsFile(r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	return specs, err
+}
+
+// parseFilterLine parses the arguments of a "!filter" line, e.g.
+// "ratelimit 5hz", into the matching built-in BlockTransformer.
+func parseFilterLine(args string) (BlockTransformer, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("i3cat: empty !filter line")
+	}
+	name, rest := fields[0], fields[1:]
+	switch name {
+	case "ratelimit":
+		if len(rest) != 1 {
+			return nil, fmt.Errorf("i3cat: !filter ratelimit takes a single RATEhz argument")
+		}
+		hz, err := parseHz(rest[0])
+		if err != nil {
+			return nil, err
+		}
+		return RateLimiter(hz), nil
+	case "markup":
+		return MarkupInjector(strings.Join(rest, " ")), nil
+	case "urgentdebounce":
+		if len(rest) != 1 {
+			return nil, fmt.Errorf("i3cat: !filter urgentdebounce takes a single duration argument")
+		}
+		d, err := time.ParseDuration(rest[0])
+		if err != nil {
+			return nil, err
+		}
+		return UrgentDebouncer(d), nil
+	case "prefix":
+		if len(rest) != 1 {
+			return nil, fmt.Errorf("i3cat: !filter prefix takes a single PREFIX argument")
+		}
+		return AutoPrefixer(rest[0]), nil
+	case "color":
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("i3cat: !filter color takes one or more NAME=VALUE pairs")
+		}
+		palette := make(map[string]string, len(rest))
+		for _, pair := range rest {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("i3cat: invalid !filter color pair %q, want NAME=VALUE", pair)
+			}
+			palette[kv[0]] = kv[1]
+		}
+		return ColorRewriter(palette), nil
+	default:
+		return nil, fmt.Errorf("i3cat: unknown filter %q", name)
+	}
+}
+
+// parseHz parses a rate such as "5hz" into its value in Hz.
+func parseHz(s string) (float64, error) {
+	s = strings.TrimSuffix(s, "hz")
+	hz, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("i3cat: invalid rate %q, want e.g. 5hz", s)
+	}
+	return hz, nil
+}
@@ -0,0 +1,99 @@
+package i3cat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vincent-petithory/i3cat/pkg/i3bar"
+)
+
+// BlockTransformer rewrites a block update before it reaches the
+// BlockAggregator. Transformers can be chained: each one receives the
+// output of the previous one and is free to mutate the blocks in place or
+// return a different slice entirely.
+type BlockTransformer func([]*i3bar.Block) []*i3bar.Block
+
+// RateLimiter returns a BlockTransformer that coalesces updates faster than
+// hz: if called again before 1/hz has elapsed since the last update it let
+// through, it returns that update again instead of the new one.
+func RateLimiter(hz float64) BlockTransformer {
+	interval := time.Duration(float64(time.Second) / hz)
+	var last time.Time
+	var held []*i3bar.Block
+	return func(blocks []*i3bar.Block) []*i3bar.Block {
+		now := time.Now()
+		if held != nil && now.Sub(last) < interval {
+			return held
+		}
+		last = now
+		held = blocks
+		return blocks
+	}
+}
+
+// ColorRewriter returns a BlockTransformer that maps a block's Color
+// through palette, leaving it untouched when it has no entry. This lets a
+// source refer to colors by name (e.g. "warn", "crit") instead of a
+// specific theme's hex codes.
+func ColorRewriter(palette map[string]string) BlockTransformer {
+	return func(blocks []*i3bar.Block) []*i3bar.Block {
+		for _, b := range blocks {
+			if c, ok := palette[b.Color]; ok {
+				b.Color = c
+			}
+		}
+		return blocks
+	}
+}
+
+// MarkupInjector returns a BlockTransformer that wraps every block's
+// FullText in a Pango <span attrs> so it can carry its own font/underline
+// styling. i3bar only parses this instead of rendering it as literal text
+// if the header's Markup field is also set to "pango", e.g. via i3cat's
+// --header-markup flag.
+func MarkupInjector(attrs string) BlockTransformer {
+	return func(blocks []*i3bar.Block) []*i3bar.Block {
+		for _, b := range blocks {
+			b.FullText = fmt.Sprintf("<span %s>%s</span>", attrs, b.FullText)
+		}
+		return blocks
+	}
+}
+
+// UrgentDebouncer returns a BlockTransformer that ignores changes to a
+// block's Urgent flag for min after the last one it let through, keyed by
+// the block's name/instance. This avoids an urgent block flapping faster
+// than a user can react to it.
+func UrgentDebouncer(min time.Duration) BlockTransformer {
+	lastChange := make(map[string]time.Time)
+	stable := make(map[string]bool)
+	return func(blocks []*i3bar.Block) []*i3bar.Block {
+		now := time.Now()
+		for _, b := range blocks {
+			key := b.Name + "\x00" + b.Instance
+			if b.Urgent != stable[key] && now.Sub(lastChange[key]) >= min {
+				stable[key] = b.Urgent
+				lastChange[key] = now
+			}
+			b.Urgent = stable[key]
+		}
+		return blocks
+	}
+}
+
+// AutoPrefixer returns a BlockTransformer that prepends prefix to every
+// block's Name, so that BlockAggregator.ForwardClickEvents can tell apart
+// two sources that happen to emit blocks under the same name. Pass the same
+// prefix as the source's namePrefix in BlockAggregator.AddSource so that a
+// click event routed back to the source has it stripped again; otherwise the
+// source would never recognize the prefixed name of its own block.
+func AutoPrefixer(prefix string) BlockTransformer {
+	return func(blocks []*i3bar.Block) []*i3bar.Block {
+		for _, b := range blocks {
+			if b.Name != "" {
+				b.Name = prefix + b.Name
+			}
+		}
+		return blocks
+	}
+}
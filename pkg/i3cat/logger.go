@@ -0,0 +1,159 @@
+package i3cat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Level is a log severity, ordered from least to most urgent.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String implements fmt.Stringer.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseLevel parses a level name such as "debug" or "error".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return 0, fmt.Errorf("i3cat: unknown log level %q", s)
+	}
+}
+
+// Format is the on-the-wire encoding of a log line.
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses a format name, either "text" or "json".
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatText, FormatJSON:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("i3cat: unknown log format %q, want text or json", s)
+	}
+}
+
+// Logger logs leveled, structured messages as alternating key/value pairs,
+// in the style of go-kit's log.Logger. Every BlockSource-facing component
+// is handed one scoped to it with With, so its lines carry a source=<cmd>
+// field and can be told apart in a bar aggregating many commands.
+type Logger interface {
+	Debug(keyvals ...interface{})
+	Info(keyvals ...interface{})
+	Warn(keyvals ...interface{})
+	Error(keyvals ...interface{})
+}
+
+// With returns a Logger that logs through logger with keyvals prepended to
+// every call, e.g. With(logger, "source", cmd).
+func With(logger Logger, keyvals ...interface{}) Logger {
+	all := make([]interface{}, 0, len(keyvals))
+	all = append(all, keyvals...)
+	return &scopedLogger{next: logger, keyvals: all}
+}
+
+type scopedLogger struct {
+	next    Logger
+	keyvals []interface{}
+}
+
+func (l *scopedLogger) Debug(keyvals ...interface{}) { l.next.Debug(l.merge(keyvals)...) }
+func (l *scopedLogger) Info(keyvals ...interface{})  { l.next.Info(l.merge(keyvals)...) }
+func (l *scopedLogger) Warn(keyvals ...interface{})  { l.next.Warn(l.merge(keyvals)...) }
+func (l *scopedLogger) Error(keyvals ...interface{}) { l.next.Error(l.merge(keyvals)...) }
+
+func (l *scopedLogger) merge(keyvals []interface{}) []interface{} {
+	all := make([]interface{}, 0, len(l.keyvals)+len(keyvals))
+	all = append(all, l.keyvals...)
+	all = append(all, keyvals...)
+	return all
+}
+
+// stdLogger is the default Logger, writing leveled, structured lines to an
+// io.Writer as either a flat "key=value" text or a JSON object, after
+// filtering out anything below minLevel.
+type stdLogger struct {
+	w        io.Writer
+	minLevel Level
+	format   Format
+}
+
+// NewLogger returns a Logger writing to w, dropping anything below
+// minLevel, formatted per format.
+func NewLogger(w io.Writer, minLevel Level, format Format) Logger {
+	return &stdLogger{w: w, minLevel: minLevel, format: format}
+}
+
+func (l *stdLogger) Debug(keyvals ...interface{}) { l.log(LevelDebug, keyvals...) }
+func (l *stdLogger) Info(keyvals ...interface{})  { l.log(LevelInfo, keyvals...) }
+func (l *stdLogger) Warn(keyvals ...interface{})  { l.log(LevelWarn, keyvals...) }
+func (l *stdLogger) Error(keyvals ...interface{}) { l.log(LevelError, keyvals...) }
+
+func (l *stdLogger) log(level Level, keyvals ...interface{}) {
+	if level < l.minLevel {
+		return
+	}
+	switch l.format {
+	case FormatJSON:
+		l.logJSON(level, keyvals)
+	default:
+		l.logText(level, keyvals)
+	}
+}
+
+func (l *stdLogger) logText(level Level, keyvals []interface{}) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "level=%s", level)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	fmt.Fprintln(l.w, sb.String())
+}
+
+func (l *stdLogger) logJSON(level Level, keyvals []interface{}) {
+	m := make(map[string]interface{}, len(keyvals)/2+1)
+	m["level"] = level.String()
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		m[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		fmt.Fprintf(l.w, `{"level":"error","msg":"i3cat: failed to marshal log line: %v"}`+"\n", err)
+		return
+	}
+	l.w.Write(append(b, '\n'))
+}
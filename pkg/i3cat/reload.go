@@ -0,0 +1,46 @@
+package i3cat
+
+import (
+	"context"
+)
+
+// reloadCmdIOs diffs the running cmdios against specs, matching on the
+// exact command string: commands no longer listed are stopped and
+// unregistered, newly listed commands are started, and anything else keeps
+// running untouched. It returns the updated list of CmdIOs.
+func reloadCmdIOs(ctx context.Context, ba *BlockAggregator, blockAggregatesCh chan<- *BlockAggregate, cmdios []*CmdIO, specs []cmdSpec, logger Logger) []*CmdIO {
+	wanted := make(map[string]cmdSpec, len(specs))
+	for _, spec := range specs {
+		wanted[spec.cmd] = spec
+	}
+
+	var kept []*CmdIO
+	for _, cmdio := range cmdios {
+		cmd := cmdio.Cmd.Args[2]
+		if _, ok := wanted[cmd]; ok {
+			kept = append(kept, cmdio)
+			delete(wanted, cmd) // already running; don't start it again below
+			continue
+		}
+		logger.Info("msg", "stopping removed command", "source", cmd)
+		ba.RemoveSource(cmdio)
+		if err := cmdio.Close(); err != nil {
+			logger.Error("msg", "failed to close command", "source", cmd, "err", err)
+		}
+	}
+
+	for _, spec := range specs {
+		if _, ok := wanted[spec.cmd]; !ok {
+			continue // already running, or listed more than once
+		}
+		cmdio, err := NewCmdIO(spec.cmd, logger)
+		if err != nil {
+			logger.Error("msg", "failed to start new command", "source", spec.cmd, "err", err)
+			continue
+		}
+		kept = append(kept, cmdio)
+		ba.AddSource(ctx, cmdio, blockAggregatesCh, spec.namePrefix, spec.filters...)
+		logger.Info("msg", "starting new command", "source", spec.cmd)
+	}
+	return kept
+}
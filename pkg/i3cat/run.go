@@ -0,0 +1,147 @@
+package i3cat
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vincent-petithory/i3cat/pkg/i3bar"
+)
+
+// Run parses the commands and filters listed in cmdsFile, starts the
+// commands, and feeds i3bar on stdout with the i3bar protocol stream
+// aggregating their output, until the process is interrupted.
+//
+// If logFile is non-empty, i3cat's own events are logged there instead of
+// stderr, as logFormat-encoded lines at logLevel and above. If debugFile is
+// non-empty, everything written to stdout is also appended to it, which is
+// useful to inspect what i3cat sends to i3bar.
+func Run(cmdsFile string, header i3bar.Header, logFile string, logLevel Level, logFormat Format, debugFile string) {
+	// The i3bar protocol lets i3bar ask us to pause/resume via these
+	// signals. SIGSTOP/SIGKILL can't be caught, so the kernel would
+	// suspend/kill i3cat itself before Run ever saw the signal; default to
+	// a pair i3cat can actually handle when unset.
+	if header.StopSignal == 0 {
+		header.StopSignal = int(syscall.SIGUSR1)
+	}
+	if header.ContSignal == 0 {
+		header.ContSignal = int(syscall.SIGUSR2)
+	}
+
+	// Read and parse commands and filters to run.
+	cmdSpecs, err := readCmdSpecs(cmdsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Init log output.
+	logOut := io.Writer(os.Stderr)
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		logOut = f
+	}
+	logger := NewLogger(logOut, logLevel, logFormat)
+
+	// Init where i3cat will print its output.
+	var out io.Writer
+	if debugFile != "" {
+		f, err := os.OpenFile(debugFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		out = io.MultiWriter(os.Stdout, f)
+	} else {
+		out = os.Stdout
+	}
+
+	// We print the header of i3bar
+	stream := i3bar.NewStream(nil, out)
+	if err := stream.WriteHeader(header); err != nil {
+		log.Fatal(err)
+	}
+
+	// Listen for click events sent from i3bar
+	cel := NewClickEventsListener(os.Stdin, logger)
+	go cel.Listen()
+
+	// Create the block aggregator and start the commands
+	ctx, cancel := context.WithCancel(context.Background())
+	blocksCh := make(chan *BlockAggregate)
+	var cmdios []*CmdIO
+	ba := NewBlockAggregator(stream, logger)
+	for _, spec := range cmdSpecs {
+		cmdio, err := NewCmdIO(spec.cmd, logger)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmdios = append(cmdios, cmdio)
+		ba.AddSource(ctx, cmdio, blocksCh, spec.namePrefix, spec.filters...)
+		logger.Info("msg", "starting command", "source", spec.cmd)
+	}
+	go ba.Aggregate(blocksCh)
+
+	ceCh := cel.Notify()
+	go ba.ForwardClickEvents(ceCh)
+
+	// Listen for worthy signals
+	stopSig := syscall.Signal(header.StopSignal)
+	contSig := syscall.Signal(header.ContSignal)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, stopSig, contSig)
+
+	for {
+		s := <-c
+		switch s {
+		case syscall.SIGTERM, os.Interrupt:
+			// Kill all processes on interrupt
+			logger.Info("msg", "SIGINT or SIGTERM received: terminating all processes...")
+			cancel()
+			for _, cmdio := range cmdios {
+				if err := cmdio.Close(); err != nil {
+					logger.Error("msg", "failed to close command", "err", err)
+				}
+			}
+			os.Exit(0)
+		case syscall.SIGHUP:
+			if cmdsFile == "-" {
+				logger.Warn("msg", "SIGHUP received: cmd-file was read from STDIN, cannot reload it")
+				continue
+			}
+			logger.Info("msg", "SIGHUP received: reloading cmd-file...")
+			newCmdSpecs, err := readCmdSpecs(cmdsFile)
+			if err != nil {
+				logger.Error("msg", "failed to reload cmd-file", "err", err)
+				continue
+			}
+			cmdios = reloadCmdIOs(ctx, ba, blocksCh, cmdios, newCmdSpecs, logger)
+		case stopSig:
+			logger.Info("msg", "stop_signal received: pausing all processes...")
+			ba.Pause()
+			for _, cmdio := range cmdios {
+				if err := cmdio.Signal(stopSig); err != nil {
+					logger.Error("msg", "failed to signal command", "err", err)
+				}
+			}
+		case contSig:
+			logger.Info("msg", "cont_signal received: resuming all processes...")
+			for _, cmdio := range cmdios {
+				if err := cmdio.Signal(contSig); err != nil {
+					logger.Error("msg", "failed to signal command", "err", err)
+				}
+			}
+			ba.Resume()
+		}
+	}
+}
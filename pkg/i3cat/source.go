@@ -0,0 +1,53 @@
+package i3cat
+
+import (
+	"context"
+	"time"
+
+	"github.com/vincent-petithory/i3cat/pkg/i3bar"
+)
+
+// BlockSource is implemented by anything that can produce i3bar blocks
+// in-process and receive click events routed back to it, without going
+// through a subprocess and its JSON framing.
+//
+// Run must block, writing each update it produces to out, until ctx is
+// canceled or it encounters an unrecoverable error, in which case it
+// returns that error. Click events addressed to one of the blocks this
+// source last emitted are delivered on clicks; a source with no
+// interactive blocks may simply ignore it.
+type BlockSource interface {
+	Run(ctx context.Context, out chan<- []*i3bar.Block, clicks <-chan i3bar.ClickEvent) error
+}
+
+// TickerSource adapts a plain polling function into a BlockSource, calling
+// Fn every Interval and emitting whatever blocks it returns. It's the
+// simplest way to register an in-process source such as a clock, a battery
+// gauge or an mpd status line, which have nothing else to do between ticks
+// and no use for click events.
+type TickerSource struct {
+	// Interval is the delay between two calls to Fn.
+	Interval time.Duration
+	// Fn produces the blocks for one update.
+	Fn func() []*i3bar.Block
+}
+
+// Run implements BlockSource.
+func (t *TickerSource) Run(ctx context.Context, out chan<- []*i3bar.Block, clicks <-chan i3bar.ClickEvent) error {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clicks:
+			// No interactive blocks by default; discard.
+		case <-ticker.C:
+			select {
+			case out <- t.Fn():
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}